@@ -0,0 +1,74 @@
+package docker_support
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// AgentAPI exposes AddAgent/RemoveAgent as HTTP endpoints so operators can register or
+// unregister remote agents at runtime without a restart, matching how Traefik's provider layer
+// accepts live configuration updates instead of requiring one. It has no opinion on
+// authentication: the caller mounts HandleAgents on the router behind whatever middleware
+// already protects the rest of the authenticated API, the same way every other operator-facing
+// route in this codebase is protected.
+type AgentAPI struct {
+	manager *RetriableClientManager
+}
+
+// NewAgentAPI creates the runtime agent-management API for manager.
+func NewAgentAPI(manager *RetriableClientManager) *AgentAPI {
+	return &AgentAPI{manager: manager}
+}
+
+// addAgentRequest is the JSON body HandleAgents expects for a POST.
+type addAgentRequest struct {
+	Endpoint string `json:"endpoint"`
+}
+
+// HandleAgents serves the runtime agent API on a single path: POST registers a new endpoint
+// from a JSON body ({"endpoint": "host:port"}) and returns the resulting docker.Host, DELETE
+// unregisters the agent whose host ID is given by the "id" query parameter.
+func (a *AgentAPI) HandleAgents(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		a.addAgent(w, r)
+	case http.MethodDelete:
+		a.removeAgent(w, r)
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *AgentAPI) addAgent(w http.ResponseWriter, r *http.Request) {
+	var req addAgentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Endpoint) == "" {
+		http.Error(w, "endpoint is required", http.StatusBadRequest)
+		return
+	}
+
+	host, err := a.manager.AddAgent(req.Endpoint)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(host)
+}
+
+func (a *AgentAPI) removeAgent(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.manager.RemoveAgent(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,109 @@
+package docker_support
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/amir20/dozzle/internal/docker"
+)
+
+func fakeDial(host docker.Host) dialAgentFunc {
+	return func(endpoint string, _ tls.Certificate) (ClientService, docker.Host, error) {
+		host.Endpoint = endpoint
+		return &fakeClientService{host: host}, host, nil
+	}
+}
+
+func TestHandleAgentsAddAndRemoveEndToEnd(t *testing.T) {
+	m := NewRetriableClientManager(tls.Certificate{}, "")
+	m.dial = fakeDial(docker.Host{ID: "host-a"})
+	api := NewAgentAPI(m)
+
+	addReq := httptest.NewRequest(http.MethodPost, "/api/agents", bytes.NewBufferString(`{"endpoint":"agent-a:7007"}`))
+	addRec := httptest.NewRecorder()
+	api.HandleAgents(addRec, addReq)
+
+	if addRec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d, want %d, body: %s", addRec.Code, http.StatusOK, addRec.Body.String())
+	}
+
+	var host docker.Host
+	if err := json.Unmarshal(addRec.Body.Bytes(), &host); err != nil {
+		t.Fatalf("decoding POST response: %v", err)
+	}
+	if host.ID != "host-a" || host.Endpoint != "agent-a:7007" {
+		t.Fatalf("got host %+v, want ID=host-a Endpoint=agent-a:7007", host)
+	}
+
+	if _, ok := m.Find("host-a"); !ok {
+		t.Fatal("expected host-a to be registered with the manager after AddAgent")
+	}
+
+	removeReq := httptest.NewRequest(http.MethodDelete, "/api/agents?id=host-a", nil)
+	removeRec := httptest.NewRecorder()
+	api.HandleAgents(removeRec, removeReq)
+
+	if removeRec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d, want %d, body: %s", removeRec.Code, http.StatusNoContent, removeRec.Body.String())
+	}
+
+	if _, ok := m.Find("host-a"); ok {
+		t.Fatal("expected host-a to be unregistered after RemoveAgent")
+	}
+}
+
+func TestHandleAgentsAddRequiresEndpoint(t *testing.T) {
+	m := NewRetriableClientManager(tls.Certificate{}, "")
+	api := NewAgentAPI(m)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/agents", bytes.NewBufferString(`{}`))
+	rec := httptest.NewRecorder()
+	api.HandleAgents(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleAgentsRemoveRequiresID(t *testing.T) {
+	m := NewRetriableClientManager(tls.Certificate{}, "")
+	api := NewAgentAPI(m)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/agents", nil)
+	rec := httptest.NewRecorder()
+	api.HandleAgents(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleAgentsRemoveUnknownIDIsNotFound(t *testing.T) {
+	m := NewRetriableClientManager(tls.Certificate{}, "")
+	api := NewAgentAPI(m)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/agents?id=does-not-exist", nil)
+	rec := httptest.NewRecorder()
+	api.HandleAgents(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleAgentsRejectsUnsupportedMethod(t *testing.T) {
+	m := NewRetriableClientManager(tls.Certificate{}, "")
+	api := NewAgentAPI(m)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/agents", nil)
+	rec := httptest.NewRecorder()
+	api.HandleAgents(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
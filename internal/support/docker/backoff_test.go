@@ -0,0 +1,55 @@
+package docker_support
+
+import "testing"
+
+func TestNextBackoffDoublesAndCapsWithJitter(t *testing.T) {
+	state := &retryState{delay: retryBaseDelay}
+
+	var delay int64
+	for i := 0; i < 20; i++ {
+		prev := state.delay
+		got := nextBackoff(state)
+
+		wantDelay := prev * 2
+		if wantDelay > retryMaxDelay {
+			wantDelay = retryMaxDelay
+		}
+		if state.delay != wantDelay {
+			t.Fatalf("iteration %d: state.delay = %v, want %v", i, state.delay, wantDelay)
+		}
+
+		// nextBackoff returns delay/2 plus jitter in [0, delay/2), so the result always falls
+		// in [delay/2, delay).
+		if got < wantDelay/2 || got >= wantDelay {
+			t.Fatalf("iteration %d: nextBackoff() = %v, want in [%v, %v)", i, got, wantDelay/2, wantDelay)
+		}
+
+		delay = int64(state.delay)
+	}
+
+	if state.delay != retryMaxDelay {
+		t.Fatalf("after repeated failures state.delay = %v, want cap %v", state.delay, retryMaxDelay)
+	}
+	if delay != int64(retryMaxDelay) {
+		t.Fatalf("delay did not settle at the cap: %v", delay)
+	}
+}
+
+func TestNextBackoffJitterIsIndependentPerState(t *testing.T) {
+	a := &retryState{delay: retryBaseDelay}
+	b := &retryState{delay: retryBaseDelay}
+
+	// Two endpoints failing in lockstep shouldn't be guaranteed to retry at the exact same
+	// moment forever; run enough iterations that at least one jitter draw differs.
+	sawDifference := false
+	for i := 0; i < 50; i++ {
+		if nextBackoff(a) != nextBackoff(b) {
+			sawDifference = true
+			break
+		}
+	}
+
+	if !sawDifference {
+		t.Fatalf("expected independent jitter to eventually diverge across 50 draws")
+	}
+}
@@ -0,0 +1,120 @@
+package docker_support
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HostEventType describes what happened to a host in a HostEvent.
+type HostEventType int
+
+const (
+	// HostAdded fires when an agent is registered for the first time, either at startup or via AddAgent.
+	HostAdded HostEventType = iota
+	// HostRemoved fires when an agent is unregistered via RemoveAgent.
+	HostRemoved
+	// HostAvailable fires when a previously unreachable agent starts responding again.
+	HostAvailable
+	// HostUnavailable fires when a connected agent's health check starts failing.
+	HostUnavailable
+	// HostUpdated marks a change to a known host's metadata without a change in availability.
+	// RetriableClientManager does not publish it; every host change it reports uses one of the
+	// types above.
+	HostUpdated
+)
+
+func (t HostEventType) String() string {
+	switch t {
+	case HostAdded:
+		return "added"
+	case HostRemoved:
+		return "removed"
+	case HostAvailable:
+		return "available"
+	case HostUnavailable:
+		return "unavailable"
+	case HostUpdated:
+		return "updated"
+	default:
+		return "unknown"
+	}
+}
+
+// HostEvent is published on the event bus whenever a host's membership or availability changes.
+type HostEvent struct {
+	Type HostEventType
+	Host docker.Host
+	At   time.Time
+}
+
+// UnsubscribeFunc detaches a subscriber registered with hostEventBus.Subscribe. It is safe to
+// call more than once.
+type UnsubscribeFunc func()
+
+// eventBusBuffer is the per-subscriber channel capacity. Events beyond this are dropped rather
+// than blocking the publisher, since a slow consumer shouldn't stall host management.
+const eventBusBuffer = 16
+
+// hostEventBus is a small in-memory pub/sub hub for HostEvent, modeled after the
+// subscribe-returns-channel-and-unsubscribe shape common to in-process event buses: subscribers
+// are tracked under an RWMutex and publishing never blocks on a slow or gone subscriber.
+type hostEventBus struct {
+	mu     sync.RWMutex
+	subs   map[int]chan HostEvent
+	nextID int
+}
+
+func newHostEventBus() *hostEventBus {
+	return &hostEventBus{
+		subs: make(map[int]chan HostEvent),
+	}
+}
+
+// Subscribe registers a new listener and returns its channel along with a function to detach
+// it. The channel is also detached automatically when ctx is done.
+func (b *hostEventBus) Subscribe(ctx context.Context) (<-chan HostEvent, UnsubscribeFunc) {
+	ch := make(chan HostEvent, eventBusBuffer)
+
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = ch
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+	}()
+
+	return ch, unsubscribe
+}
+
+// publish delivers event to every current subscriber without blocking: a subscriber whose
+// buffer is full drops the event rather than stalling the caller.
+func (b *hostEventBus) publish(event HostEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+			log.Warnf("dropping host event %s for %s: subscriber buffer full", event.Type, event.Host.ID)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package docker_support
+
+import (
+	"context"
+	"testing"
+
+	"github.com/amir20/dozzle/internal/docker"
+)
+
+func TestHostEventBusDeliversToSubscriber(t *testing.T) {
+	bus := newHostEventBus()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, unsubscribe := bus.Subscribe(ctx)
+	defer unsubscribe()
+
+	bus.publish(HostEvent{Type: HostAdded, Host: docker.Host{ID: "host-0"}})
+
+	select {
+	case event := <-ch:
+		if event.Type != HostAdded || event.Host.ID != "host-0" {
+			t.Fatalf("got event %+v, want Type=%v Host.ID=host-0", event, HostAdded)
+		}
+	default:
+		t.Fatal("expected the published event to be immediately available, got nothing")
+	}
+}
+
+// TestHostEventBusDropsOnFullBuffer asserts publish's documented drop-on-full behavior: once a
+// subscriber's buffer is saturated, further events are discarded rather than blocking the
+// publisher or ever becoming visible to a later, slower reader.
+func TestHostEventBusDropsOnFullBuffer(t *testing.T) {
+	bus := newHostEventBus()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, unsubscribe := bus.Subscribe(ctx)
+	defer unsubscribe()
+
+	const published = eventBusBuffer + 5
+	for i := 0; i < published; i++ {
+		bus.publish(HostEvent{Type: HostAdded, Host: docker.Host{ID: idFor(i)}})
+	}
+
+	for i := 0; i < eventBusBuffer; i++ {
+		select {
+		case event := <-ch:
+			if event.Host.ID != idFor(i) {
+				t.Fatalf("event %d: got Host.ID %q, want %q", i, event.Host.ID, idFor(i))
+			}
+		default:
+			t.Fatalf("event %d: expected a buffered event, channel was empty", i)
+		}
+	}
+
+	select {
+	case event := <-ch:
+		t.Fatalf("expected the buffer to be exhausted after %d events, got extra event %+v", eventBusBuffer, event)
+	default:
+	}
+}
+
+func idFor(i int) string {
+	return "host-" + string(rune('0'+i%10))
+}
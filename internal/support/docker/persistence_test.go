@@ -0,0 +1,73 @@
+package docker_support
+
+import (
+	"crypto/tls"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// TestPersistAgentsRoundTrip exercises the full persistAgents -> loadPersistedAgents cycle
+// against a real file, the same way a process restart does, asserting AddAgent's endpoints
+// come back out exactly as they were written.
+func TestPersistAgentsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agents.json")
+
+	m := NewRetriableClientManager(tls.Certificate{}, path)
+	m.dynamicAgents["agent-a:8080"] = "id-a"
+	m.dynamicAgents["agent-b:8080"] = "id-b"
+
+	m.persistAgents()
+
+	got, err := loadPersistedAgents(path)
+	if err != nil {
+		t.Fatalf("loadPersistedAgents() error = %v", err)
+	}
+
+	sort.Strings(got)
+	want := []string{"agent-a:8080", "agent-b:8080"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("loadPersistedAgents() = %v, want %v", got, want)
+	}
+}
+
+// TestLoadPersistedAgentsMissingFile asserts a never-yet-written persistence file is treated as
+// "nothing persisted" rather than an error, since that's the normal state on first run.
+func TestLoadPersistedAgentsMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "does-not-exist.json")
+
+	got, err := loadPersistedAgents(path)
+	if err != nil {
+		t.Fatalf("loadPersistedAgents() error = %v, want nil for a missing file", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("loadPersistedAgents() = %v, want empty", got)
+	}
+}
+
+// TestNewRetriableClientManagerSeedsDynamicAgentsFromPersistence guards against the bug where
+// restored endpoints were handed to a bare StaticAgentProvider but never added to
+// m.dynamicAgents: since persistAgents only ever serializes dynamicAgents, that silently dropped
+// every previously-persisted agent from disk on the very next AddAgent/RemoveAgent call after a
+// restart.
+func TestNewRetriableClientManagerSeedsDynamicAgentsFromPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agents.json")
+
+	seed := NewRetriableClientManager(tls.Certificate{}, path)
+	seed.dynamicAgents["agent-a:8080"] = "id-a"
+	seed.persistAgents()
+
+	restarted := NewRetriableClientManager(tls.Certificate{}, path)
+	if _, ok := restarted.dynamicAgents["agent-a:8080"]; !ok {
+		t.Fatalf("dynamicAgents after restart = %v, want agent-a:8080 seeded from %s", restarted.dynamicAgents, path)
+	}
+
+	restarted.persistAgents()
+	got, err := loadPersistedAgents(path)
+	if err != nil {
+		t.Fatalf("loadPersistedAgents() error = %v", err)
+	}
+	if len(got) != 1 || got[0] != "agent-a:8080" {
+		t.Fatalf("loadPersistedAgents() after a second persist = %v, want [agent-a:8080] to survive", got)
+	}
+}
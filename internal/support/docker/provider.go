@@ -0,0 +1,340 @@
+package docker_support
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/amir20/dozzle/internal/agent"
+	"github.com/amir20/dozzle/internal/docker"
+	lop "github.com/samber/lo/parallel"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ProviderEventType describes what a Provider is telling the manager happened to a ClientService.
+type ProviderEventType int
+
+const (
+	// ProviderAdd announces a newly reachable ClientService, whether this is the first time the
+	// provider has seen it or it's recovering after a ProviderRemove.
+	ProviderAdd ProviderEventType = iota
+	// ProviderRemove announces that a previously announced ClientService is gone, whether because
+	// it stopped responding or because the provider's source no longer lists it.
+	ProviderRemove
+)
+
+// ProviderEvent is sent by a Provider to announce a ClientService coming or going. Host is
+// always populated so the manager can attribute the event without calling back into a client
+// that may already be failing; Client is only set for ProviderAdd.
+type ProviderEvent struct {
+	Type   ProviderEventType
+	Client ClientService
+	Host   docker.Host
+}
+
+// Provider is a source of ClientServices that the manager multiplexes alongside any other
+// configured providers, modeled on Traefik's provider pattern. Provide runs until ctx is
+// cancelled, pushing ProviderAdd/ProviderRemove events to out as clients come and go; it
+// returns ctx.Err() once ctx is done. A Provider owns whatever discovery, connection, and retry
+// policy makes sense for its source — the manager doesn't know or care how an event came to be.
+type Provider interface {
+	Provide(ctx context.Context, out chan<- ProviderEvent) error
+}
+
+// Retriggerable is an optional capability a Provider can implement to let RetryAndList nudge it
+// into retrying immediately, bypassing whatever backoff or polling interval it would otherwise
+// observe. Providers with nothing to retry (LocalDockerProvider) simply don't implement it.
+type Retriggerable interface {
+	RetryNow()
+}
+
+// PendingLister is an optional capability a Provider can implement to report sources it knows
+// about but hasn't connected to yet, so Hosts() can surface them as unavailable instead of
+// pretending they don't exist.
+type PendingLister interface {
+	Pending() []docker.Host
+}
+
+// Forgettable is an optional capability a Provider can implement to let RemoveAgent tell it to
+// stop tracking an endpoint. Without this, a Provider-sourced agent removed via RemoveAgent
+// would just be re-announced with a ProviderAdd on the provider's very next retry or
+// health-check tick, since the provider never learned it was supposed to stop watching that
+// endpoint. Providers with nothing to forget (LocalDockerProvider) simply don't implement it.
+type Forgettable interface {
+	Forget(endpoint string)
+}
+
+// staticAgent tracks a successfully connected agent endpoint, along with the host info captured
+// at connect time so it can be reported without calling back into an already-failing client.
+type staticAgent struct {
+	client ClientService
+	host   docker.Host
+}
+
+// dialAgent connects to endpoint and fetches its host info, wrapping the concrete agent client in
+// a ClientService. It's a field on StaticAgentProvider rather than a direct call so tests can
+// substitute a fake dialer instead of needing a real agent listening on endpoint.
+type dialAgentFunc func(endpoint string, certs tls.Certificate) (ClientService, docker.Host, error)
+
+func dialAgent(endpoint string, certs tls.Certificate) (ClientService, docker.Host, error) {
+	client, err := agent.NewClient(endpoint, certs)
+	if err != nil {
+		return nil, docker.Host{}, fmt.Errorf("creating agent client for %s: %w", endpoint, err)
+	}
+
+	host, err := client.Host()
+	if err != nil {
+		return nil, docker.Host{}, fmt.Errorf("fetching host info for agent %s: %w", endpoint, err)
+	}
+
+	return NewAgentService(client), host, nil
+}
+
+// StaticAgentProvider connects to a fixed list of remote agent endpoints and keeps them alive:
+// it retries failed endpoints with the same per-endpoint exponential backoff as the manager's
+// own Serve retry loop, and health-checks connected ones so a dead agent is reported and retried
+// rather than silently going stale.
+type StaticAgentProvider struct {
+	certs tls.Certificate
+
+	mu          sync.Mutex
+	failed      []string
+	retryStates map[string]*retryState
+	connected   map[string]staticAgent // endpoint -> connected agent
+	trigger     chan struct{}
+	dial        dialAgentFunc
+}
+
+// NewStaticAgentProvider creates a provider for the given agent endpoints, dialed with certs.
+func NewStaticAgentProvider(agents []string, certs tls.Certificate) *StaticAgentProvider {
+	return &StaticAgentProvider{
+		certs:       certs,
+		failed:      append([]string(nil), agents...),
+		retryStates: make(map[string]*retryState),
+		connected:   make(map[string]staticAgent),
+		trigger:     make(chan struct{}, 1),
+		dial:        dialAgent,
+	}
+}
+
+// Provide connects to every configured endpoint, retrying failures on a backoff and
+// health-checking successes, until ctx is cancelled.
+func (p *StaticAgentProvider) Provide(ctx context.Context, out chan<- ProviderEvent) error {
+	ticker := time.NewTicker(retryTick)
+	defer ticker.Stop()
+
+	healthTicker := time.NewTicker(healthCheckTick)
+	defer healthTicker.Stop()
+
+	p.retryDue(ctx, time.Now(), out)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.trigger:
+			p.retryDue(ctx, time.Now(), out)
+		case <-ticker.C:
+			p.retryDue(ctx, time.Now(), out)
+		case <-healthTicker.C:
+			p.healthCheckDue(ctx, out)
+		}
+	}
+}
+
+// retryDue attempts to reconnect to every failed endpoint whose backoff has elapsed.
+func (p *StaticAgentProvider) retryDue(ctx context.Context, now time.Time, out chan<- ProviderEvent) {
+	p.mu.Lock()
+	if len(p.failed) == 0 {
+		p.mu.Unlock()
+		return
+	}
+
+	newFailed := make([]string, 0, len(p.failed))
+	var toSend []ProviderEvent
+	for _, endpoint := range p.failed {
+		state := p.retryStates[endpoint]
+		if state == nil {
+			state = &retryState{delay: retryBaseDelay, next: now}
+			p.retryStates[endpoint] = state
+		}
+
+		if now.Before(state.next) {
+			newFailed = append(newFailed, endpoint)
+			continue
+		}
+
+		client, host, err := p.dial(endpoint, p.certs)
+		if err != nil {
+			log.Warnf("error connecting to agent %s: %v", endpoint, err)
+			newFailed = append(newFailed, endpoint)
+			state.next = now.Add(nextBackoff(state))
+			continue
+		}
+
+		host.Available = true
+		conn := staticAgent{client: client, host: host}
+		p.connected[endpoint] = conn
+		delete(p.retryStates, endpoint)
+		toSend = append(toSend, ProviderEvent{Type: ProviderAdd, Client: conn.client, Host: host})
+	}
+	p.failed = newFailed
+	p.mu.Unlock()
+
+	for _, event := range toSend {
+		select {
+		case out <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// healthCheckDue calls Host() on every connected endpoint and, for any that starts failing,
+// moves it back into failed so retryDue picks it up, and announces its removal. The Host()
+// calls run concurrently and without p.mu held, so a batch of agents going down at once can't
+// block Forget/RetryNow/Pending (or another Provide tick) for as long as the slowest one takes
+// to time out.
+func (p *StaticAgentProvider) healthCheckDue(ctx context.Context, out chan<- ProviderEvent) {
+	p.mu.Lock()
+	candidates := make([]string, 0, len(p.connected))
+	for endpoint := range p.connected {
+		candidates = append(candidates, endpoint)
+	}
+	conns := make(map[string]staticAgent, len(candidates))
+	for _, endpoint := range candidates {
+		conns[endpoint] = p.connected[endpoint]
+	}
+	p.mu.Unlock()
+
+	type probeResult struct {
+		endpoint string
+		err      error
+	}
+
+	results := lop.Map(candidates, func(endpoint string, _ int) probeResult {
+		_, err := conns[endpoint].client.Host()
+		return probeResult{endpoint: endpoint, err: err}
+	})
+
+	p.mu.Lock()
+	var toSend []ProviderEvent
+	for _, result := range results {
+		if result.err == nil {
+			continue
+		}
+
+		conn, ok := p.connected[result.endpoint]
+		if !ok {
+			// forgotten or already marked down since the snapshot above
+			continue
+		}
+
+		log.Warnf("agent %s failed health check, marking unavailable", result.endpoint)
+		delete(p.connected, result.endpoint)
+		p.failed = append(p.failed, result.endpoint)
+
+		host := conn.host
+		host.Available = false
+		toSend = append(toSend, ProviderEvent{Type: ProviderRemove, Host: host})
+	}
+	p.mu.Unlock()
+
+	for _, event := range toSend {
+		select {
+		case out <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RetryNow resets every failed endpoint's backoff and wakes Provide so it retries immediately,
+// satisfying Retriggerable.
+func (p *StaticAgentProvider) RetryNow() {
+	p.mu.Lock()
+	for _, state := range p.retryStates {
+		state.next = time.Time{}
+	}
+	p.mu.Unlock()
+
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+		// a retry is already pending
+	}
+}
+
+// Forget drops endpoint from both the connected and failed sets so Provide stops retrying and
+// re-announcing it, satisfying Forgettable. It's a no-op if endpoint isn't tracked.
+func (p *StaticAgentProvider) Forget(endpoint string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.connected, endpoint)
+	delete(p.retryStates, endpoint)
+
+	newFailed := p.failed[:0:0]
+	for _, e := range p.failed {
+		if e != endpoint {
+			newFailed = append(newFailed, e)
+		}
+	}
+	p.failed = newFailed
+}
+
+// Pending reports endpoints that are configured but not currently connected, satisfying
+// PendingLister.
+func (p *StaticAgentProvider) Pending() []docker.Host {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hosts := make([]docker.Host, 0, len(p.failed))
+	for _, endpoint := range p.failed {
+		hosts = append(hosts, docker.Host{
+			ID:        endpoint,
+			Name:      endpoint,
+			Endpoint:  endpoint,
+			Available: false,
+			Type:      "agent",
+		})
+	}
+	return hosts
+}
+
+// LocalDockerProvider wraps ClientServices that are already connected at construction time,
+// such as the local Docker socket, and announces them once when Provide starts. They aren't
+// health-checked or retried: a local client going away is treated as a process restart, not a
+// recoverable condition.
+type LocalDockerProvider struct {
+	clients []ClientService
+}
+
+// NewLocalDockerProvider wraps already-connected clients as a Provider.
+func NewLocalDockerProvider(clients ...ClientService) *LocalDockerProvider {
+	return &LocalDockerProvider{clients: clients}
+}
+
+// Provide announces every wrapped client once, then blocks until ctx is cancelled.
+func (p *LocalDockerProvider) Provide(ctx context.Context, out chan<- ProviderEvent) error {
+	for _, client := range p.clients {
+		host, err := client.Host()
+		if err != nil {
+			log.Warnf("error fetching host info for local client: %v", err)
+			continue
+		}
+
+		host.Available = true
+		select {
+		case out <- ProviderEvent{Type: ProviderAdd, Client: client, Host: host}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -0,0 +1,99 @@
+package docker_support
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+)
+
+// fakeClientService is a minimal ClientService stand-in for tests in this package, which only
+// ever calls Host() on a connected client.
+type fakeClientService struct {
+	host docker.Host
+	err  error
+}
+
+func (f *fakeClientService) Host() (docker.Host, error) {
+	return f.host, f.err
+}
+
+func TestStaticAgentProviderReconnectsOnBackoffAndEmitsProviderAdd(t *testing.T) {
+	p := NewStaticAgentProvider([]string{"agent-a:7007"}, tls.Certificate{})
+	p.dial = func(endpoint string, _ tls.Certificate) (ClientService, docker.Host, error) {
+		return &fakeClientService{host: docker.Host{ID: "host-a", Endpoint: endpoint}}, docker.Host{ID: "host-a", Endpoint: endpoint}, nil
+	}
+
+	out := make(chan ProviderEvent, 1)
+	p.retryDue(context.Background(), time.Now(), out)
+
+	select {
+	case event := <-out:
+		if event.Type != ProviderAdd || event.Host.ID != "host-a" {
+			t.Fatalf("got event %+v, want ProviderAdd for host-a", event)
+		}
+	default:
+		t.Fatal("expected retryDue to emit a ProviderAdd event, got nothing")
+	}
+
+	p.mu.Lock()
+	failed := len(p.failed)
+	p.mu.Unlock()
+	if failed != 0 {
+		t.Fatalf("p.failed has %d entries, want empty after a successful reconnect", failed)
+	}
+}
+
+func TestStaticAgentProviderHealthCheckEmitsProviderRemoveOnFailure(t *testing.T) {
+	p := NewStaticAgentProvider(nil, tls.Certificate{})
+	p.connected["agent-a:7007"] = staticAgent{
+		client: &fakeClientService{err: errors.New("connection refused")},
+		host:   docker.Host{ID: "host-a", Endpoint: "agent-a:7007"},
+	}
+
+	out := make(chan ProviderEvent, 1)
+	p.healthCheckDue(context.Background(), out)
+
+	select {
+	case event := <-out:
+		if event.Type != ProviderRemove || event.Host.ID != "host-a" {
+			t.Fatalf("got event %+v, want ProviderRemove for host-a", event)
+		}
+	default:
+		t.Fatal("expected healthCheckDue to emit a ProviderRemove event, got nothing")
+	}
+
+	p.mu.Lock()
+	_, stillConnected := p.connected["agent-a:7007"]
+	p.mu.Unlock()
+	if stillConnected {
+		t.Fatal("expected the failed endpoint to be moved out of connected")
+	}
+}
+
+func TestStaticAgentProviderForgetStopsReannouncement(t *testing.T) {
+	p := NewStaticAgentProvider([]string{"agent-a:7007"}, tls.Certificate{})
+	dialed := false
+	p.dial = func(endpoint string, _ tls.Certificate) (ClientService, docker.Host, error) {
+		dialed = true
+		return &fakeClientService{host: docker.Host{ID: "host-a", Endpoint: endpoint}}, docker.Host{ID: "host-a", Endpoint: endpoint}, nil
+	}
+
+	p.Forget("agent-a:7007")
+
+	out := make(chan ProviderEvent, 1)
+	p.retryDue(context.Background(), time.Now(), out)
+
+	if dialed {
+		t.Fatal("expected Forget to stop retryDue from ever dialing a forgotten endpoint again")
+	}
+
+	select {
+	case event := <-out:
+		t.Fatalf("expected no event after forgetting the only failed endpoint, got %+v", event)
+	default:
+	}
+}
@@ -3,126 +3,519 @@ package docker_support
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"math/rand"
+	"os"
 	"sync"
+	"time"
 
-	"github.com/amir20/dozzle/internal/agent"
 	"github.com/amir20/dozzle/internal/docker"
-	"github.com/puzpuzpuz/xsync/v3"
 	lop "github.com/samber/lo/parallel"
 
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	retryBaseDelay  = 1 * time.Second
+	retryMaxDelay   = 5 * time.Minute
+	retryTick       = 1 * time.Second
+	healthCheckTick = 10 * time.Second
+)
+
+// retryState tracks the exponential backoff schedule for a single failed agent endpoint.
+type retryState struct {
+	delay time.Duration
+	next  time.Time
+}
+
 type RetriableClientManager struct {
-	clients      map[string]ClientService
-	failedAgents []string
-	certs        tls.Certificate
-	mu           sync.RWMutex
-	subscribers  *xsync.MapOf[context.Context, chan<- docker.Host]
+	clients         map[string]ClientService
+	knownHosts      map[string]docker.Host // client ID -> host info captured when the client was last known good
+	failedAgents    []string
+	retryStates     map[string]*retryState
+	certs           tls.Certificate
+	mu              sync.RWMutex
+	events          *hostEventBus
+	trigger         chan struct{}
+	persistPath     string
+	dynamicAgents   map[string]string // endpoint -> client ID, for agents added via AddAgent
+	providers       []Provider
+	providerManaged map[string]bool // client IDs owned by a Provider; skipped by the generic health check since providers self-monitor
+	dial            dialAgentFunc   // connects to an endpoint; overridden by tests to avoid a real network dial
+}
+
+// persistedAgents is the on-disk representation written to persistPath whenever AddAgent or
+// RemoveAgent changes the dynamically-registered agent set.
+type persistedAgents struct {
+	Endpoints []string `json:"endpoints"`
 }
 
-func NewRetriableClientManager(agents []string, certs tls.Certificate, clients ...ClientService) *RetriableClientManager {
-	log.Debugf("creating retriable client manager with %d clients and %d agents", len(clients), len(agents))
+// NewRetriableClientManager creates a manager that multiplexes the given providers — typically
+// a LocalDockerProvider for the local socket and a StaticAgentProvider for configured remote
+// agents, plus whatever discovery sources (Swarm, Kubernetes, a file watch) the caller wants.
+// Providers don't connect until Serve is called. If persistPath is non-empty, agents previously
+// added at runtime via AddAgent are loaded from that file and wrapped in their own
+// StaticAgentProvider, and future AddAgent/RemoveAgent calls keep the file in sync so the
+// dynamic set survives a restart.
+func NewRetriableClientManager(certs tls.Certificate, persistPath string, providers ...Provider) *RetriableClientManager {
+	log.Debugf("creating retriable client manager with %d providers", len(providers))
 
-	clientMap := make(map[string]ClientService)
-	for _, client := range clients {
-		host, err := client.Host()
+	dynamicAgents := make(map[string]string)
+
+	if persistPath != "" {
+		if persisted, err := loadPersistedAgents(persistPath); err != nil {
+			log.Warnf("error loading persisted agents from %s: %v", persistPath, err)
+		} else if len(persisted) > 0 {
+			providers = append(providers, NewStaticAgentProvider(persisted, certs))
+			// Seed dynamicAgents now, before any of these endpoints have connected, so that
+			// persistAgents (which only ever serializes dynamicAgents) keeps writing them back
+			// to persistPath even if AddAgent/RemoveAgent is called before a provider event
+			// fills in the host ID below.
+			for _, endpoint := range persisted {
+				dynamicAgents[endpoint] = ""
+			}
+		}
+	}
+
+	return &RetriableClientManager{
+		clients:         make(map[string]ClientService),
+		knownHosts:      make(map[string]docker.Host),
+		failedAgents:    make([]string, 0),
+		retryStates:     make(map[string]*retryState),
+		certs:           certs,
+		events:          newHostEventBus(),
+		trigger:         make(chan struct{}, 1),
+		persistPath:     persistPath,
+		dynamicAgents:   dynamicAgents,
+		providers:       providers,
+		providerManaged: make(map[string]bool),
+		dial:            dialAgent,
+	}
+}
+
+// loadPersistedAgents reads the endpoint list written by a previous process's AddAgent calls.
+// A missing file is not an error: it just means nothing has been added yet.
+func loadPersistedAgents(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var persisted persistedAgents
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return nil, err
+	}
+
+	return persisted.Endpoints, nil
+}
+
+// persistAgents writes the manager's current dynamically-added endpoints (both connected and
+// still-failed) to persistPath. It is a no-op when persistence isn't configured. Must be called
+// without m.mu held.
+func (m *RetriableClientManager) persistAgents() {
+	if m.persistPath == "" {
+		return
+	}
+
+	m.mu.RLock()
+	endpoints := make([]string, 0, len(m.dynamicAgents))
+	for endpoint := range m.dynamicAgents {
+		endpoints = append(endpoints, endpoint)
+	}
+	m.mu.RUnlock()
+
+	data, err := json.Marshal(persistedAgents{Endpoints: endpoints})
+	if err != nil {
+		log.Warnf("error marshalling persisted agents: %v", err)
+		return
+	}
+
+	if err := os.WriteFile(m.persistPath, data, 0o600); err != nil {
+		log.Warnf("error writing persisted agents to %s: %v", m.persistPath, err)
+	}
+}
+
+// Serve starts every configured provider and runs the background retry loop until ctx is
+// cancelled. It periodically retries failedAgents (endpoints registered directly via AddAgent,
+// not through a Provider such as Swarm discovery) using a per-endpoint exponential backoff (with
+// jitter, capped at retryMaxDelay) so that a flood of down agents doesn't get hammered in
+// lockstep, and resets an endpoint's backoff to retryBaseDelay as soon as it comes back online.
+// Callers that want to trigger an immediate retry attempt (e.g. after an operator action) can
+// nudge the loop via RetryAndList instead of retrying inline.
+func (m *RetriableClientManager) Serve(ctx context.Context) error {
+	providerEvents := make(chan ProviderEvent)
+	for _, provider := range m.providers {
+		provider := provider
+		go func() {
+			if err := provider.Provide(ctx, providerEvents); err != nil && ctx.Err() == nil {
+				log.Warnf("provider %T stopped unexpectedly: %v", provider, err)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(retryTick)
+	defer ticker.Stop()
+
+	healthTicker := time.NewTicker(healthCheckTick)
+	defer healthTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.trigger:
+			m.retryDue(time.Now())
+		case <-ticker.C:
+			m.retryDue(time.Now())
+		case <-healthTicker.C:
+			m.healthCheckDue()
+		case event := <-providerEvents:
+			m.handleProviderEvent(event)
+		}
+	}
+}
+
+// retryDue attempts to reconnect to every failed agent whose backoff has elapsed.
+func (m *RetriableClientManager) retryDue(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.failedAgents) == 0 {
+		return
+	}
+
+	newFailed := make([]string, 0, len(m.failedAgents))
+	for _, endpoint := range m.failedAgents {
+		state := m.retryStates[endpoint]
+		if state == nil {
+			state = &retryState{delay: retryBaseDelay, next: now}
+			m.retryStates[endpoint] = state
+		}
+
+		if now.Before(state.next) {
+			newFailed = append(newFailed, endpoint)
+			continue
+		}
+
+		client, host, err := m.dial(endpoint, m.certs)
 		if err != nil {
-			log.Warnf("error fetching host info for client %s: %v", host.ID, err)
+			log.Warnf("error connecting to agent %s: %v", endpoint, err)
+			newFailed = append(newFailed, endpoint)
+			state.next = now.Add(nextBackoff(state))
 			continue
 		}
 
-		if _, ok := clientMap[host.ID]; ok {
-			log.Warnf("duplicate client found for host %s", host.ID)
-		} else {
-			clientMap[host.ID] = client
+		m.clients[host.ID] = client
+		delete(m.retryStates, endpoint)
+		if _, tracked := m.dynamicAgents[endpoint]; tracked {
+			// fills in the host ID for an AddAgent endpoint that couldn't connect at first
+			m.dynamicAgents[endpoint] = host.ID
 		}
+
+		host.Available = true
+		m.knownHosts[host.ID] = host
+		m.publish(HostAvailable, host)
 	}
+	m.failedAgents = newFailed
+}
 
-	failed := make([]string, 0)
-	for _, endpoint := range agents {
-		agent, err := agent.NewClient(endpoint, certs)
-		if err != nil {
-			log.Warnf("error creating agent client for %s: %v", endpoint, err)
-			failed = append(failed, endpoint)
+// healthCheckCandidate is a snapshot of one client to probe in healthCheckDue, taken under
+// m.mu.RLock so the Host() round trips themselves can run without holding the lock.
+type healthCheckCandidate struct {
+	id     string
+	client ClientService
+}
+
+// healthCheckDue calls Host() on every currently connected client that wasn't registered by a
+// Provider (Providers health-check their own clients) and, for any that starts failing, moves
+// it from clients to failedAgents (so the retry loop takes over) and emits HostUnavailable. The
+// Host() calls run concurrently and without m.mu held, the same way Hosts() does it, so a batch
+// of down agents can't block List/Find/AddAgent/RemoveAgent/retryDue for as long as the
+// slowest one takes to time out.
+func (m *RetriableClientManager) healthCheckDue() {
+	m.mu.RLock()
+	candidates := make([]healthCheckCandidate, 0, len(m.clients))
+	for id, client := range m.clients {
+		if m.providerManaged[id] {
 			continue
 		}
+		candidates = append(candidates, healthCheckCandidate{id: id, client: client})
+	}
+	m.mu.RUnlock()
 
-		host, err := agent.Host()
-		if err != nil {
-			log.Warnf("error fetching host info for agent %s: %v", endpoint, err)
-			failed = append(failed, endpoint)
+	type probeResult struct {
+		id   string
+		host docker.Host
+		err  error
+	}
+
+	results := lop.Map(candidates, func(c healthCheckCandidate, _ int) probeResult {
+		host, err := c.client.Host()
+		return probeResult{id: c.id, host: host, err: err}
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, result := range results {
+		if result.err == nil {
 			continue
 		}
 
-		if _, ok := clientMap[host.ID]; ok {
-			log.Warnf("duplicate client found for host %s", host.ID)
+		// the client may have been removed, or claimed by a Provider, since the snapshot above
+		if m.providerManaged[result.id] {
+			continue
+		}
+		if _, ok := m.clients[result.id]; !ok {
+			continue
+		}
+
+		log.Warnf("agent %s failed health check, marking unavailable: %v", result.id, result.err)
+		delete(m.clients, result.id)
+
+		host := result.host
+		host.ID = result.id
+		if host.Endpoint == "" {
+			host.Endpoint = m.knownHosts[result.id].Endpoint
+			host.Name = m.knownHosts[result.id].Name
+		}
+		if host.Endpoint != "" {
+			m.failedAgents = append(m.failedAgents, host.Endpoint)
+		}
+
+		host.Available = false
+		delete(m.knownHosts, result.id)
+		m.publish(HostUnavailable, host)
+	}
+}
+
+// handleProviderEvent applies a ProviderAdd/ProviderRemove event to m.clients and publishes the
+// corresponding HostEvent. An add is reported as HostAdded the first time a host ID is seen and
+// HostAvailable on every subsequent add (i.e. recovery after a ProviderRemove).
+func (m *RetriableClientManager) handleProviderEvent(event ProviderEvent) {
+	switch event.Type {
+	case ProviderAdd:
+		m.mu.Lock()
+		_, existed := m.knownHosts[event.Host.ID]
+		m.clients[event.Host.ID] = event.Client
+		m.providerManaged[event.Host.ID] = true
+		m.knownHosts[event.Host.ID] = event.Host
+		if _, tracked := m.dynamicAgents[event.Host.Endpoint]; tracked {
+			// Fills in the host ID for an endpoint reloaded from persistPath at startup (seeded
+			// with an empty ID in NewRetriableClientManager since it wasn't connected yet), and
+			// keeps it current if the ID ever changes on reconnect.
+			m.dynamicAgents[event.Host.Endpoint] = event.Host.ID
+		}
+		m.mu.Unlock()
+
+		if existed {
+			m.publish(HostAvailable, event.Host)
 		} else {
-			clientMap[host.ID] = NewAgentService(agent)
+			m.publish(HostAdded, event.Host)
 		}
+	case ProviderRemove:
+		m.mu.Lock()
+		delete(m.clients, event.Host.ID)
+		delete(m.providerManaged, event.Host.ID)
+		// knownHosts is kept so a later ProviderAdd for this ID is reported as a recovery
+		m.mu.Unlock()
+
+		m.publish(HostUnavailable, event.Host)
 	}
+}
 
-	return &RetriableClientManager{
-		clients:      clientMap,
-		failedAgents: failed,
-		certs:        certs,
-		subscribers:  xsync.NewMapOf[context.Context, chan<- docker.Host](),
+// publish emits a HostEvent on the event bus. It must be called with m.mu held or not held
+// consistently with its callers above; the bus itself is safe for concurrent use either way.
+func (m *RetriableClientManager) publish(eventType HostEventType, host docker.Host) {
+	m.events.publish(HostEvent{
+		Type: eventType,
+		Host: host,
+		At:   time.Now(),
+	})
+}
+
+// addEndpoint connects to a new agent endpoint and, on success, registers it as a client and
+// notifies subscribers that the host was added. On failure the endpoint is queued into
+// failedAgents so the Serve retry loop keeps trying it. It is unexported because it's only
+// reached via AddAgent; other discovery sources (swarm, etc.) are Providers and own their own
+// connections instead.
+func (m *RetriableClientManager) addEndpoint(endpoint string) (docker.Host, error) {
+	client, host, err := m.dial(endpoint, m.certs)
+	if err != nil {
+		m.mu.Lock()
+		m.failedAgents = append(m.failedAgents, endpoint)
+		m.mu.Unlock()
+		return docker.Host{}, fmt.Errorf("connecting to agent %s: %w", endpoint, err)
 	}
+
+	m.mu.Lock()
+	m.clients[host.ID] = client
+	host.Available = true
+	m.knownHosts[host.ID] = host
+	m.mu.Unlock()
+
+	m.publish(HostAdded, host)
+
+	return host, nil
 }
 
-func (m *RetriableClientManager) Subscribe(ctx context.Context, channel chan<- docker.Host) {
-	m.subscribers.Store(ctx, channel)
+// removeEndpointByID drops a client from the manager and notifies subscribers that the host is
+// gone. It is a no-op error if id isn't currently a registered client. The removed host is
+// reported from knownHosts (captured the last time the client was reachable) rather than by
+// calling client.Host() again: removal is most often triggered because the client just stopped
+// responding, so a fresh call would come back empty and the published event would carry no
+// Name/Endpoint for subscribers to act on.
+func (m *RetriableClientManager) removeEndpointByID(id string) (docker.Host, error) {
+	m.mu.Lock()
+	if _, ok := m.clients[id]; !ok {
+		m.mu.Unlock()
+		return docker.Host{}, fmt.Errorf("no client found for id %s", id)
+	}
 
-	go func() {
-		<-ctx.Done()
-		m.subscribers.Delete(ctx)
-	}()
+	host, ok := m.knownHosts[id]
+	if !ok {
+		host = docker.Host{ID: id}
+	}
+
+	delete(m.clients, id)
+	delete(m.knownHosts, id)
+	m.mu.Unlock()
+
+	host.Available = false
+	m.publish(HostRemoved, host)
+
+	return host, nil
 }
 
-func (m *RetriableClientManager) RetryAndList() ([]ClientService, []error) {
+// nextBackoff doubles state.delay (capped at retryMaxDelay) and returns the delay to use,
+// with independent jitter so that endpoints which failed together don't retry in lockstep.
+func nextBackoff(state *retryState) time.Duration {
+	delay := state.delay * 2
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	state.delay = delay
+
+	jitter := time.Duration(rand.Int63n(int64(delay) / 2))
+	return delay/2 + jitter
+}
+
+// AddAgent registers a new remote agent endpoint at runtime, without requiring a restart. On
+// success the endpoint is persisted (if persistence is configured) so it survives one. If the
+// agent can't be reached right now it's still remembered and handed to the Serve retry loop,
+// matching how statically configured agents behave at startup.
+func (m *RetriableClientManager) AddAgent(endpoint string) (docker.Host, error) {
+	host, err := m.addEndpoint(endpoint)
+
 	m.mu.Lock()
-	errors := make([]error, 0)
-	if len(m.failedAgents) > 0 {
-		newFailed := make([]string, 0)
-		for _, endpoint := range m.failedAgents {
-			agent, err := agent.NewClient(endpoint, m.certs)
-			if err != nil {
-				log.Warnf("error creating agent client for %s: %v", endpoint, err)
-				errors = append(errors, err)
-				newFailed = append(newFailed, endpoint)
-				continue
-			}
+	m.dynamicAgents[endpoint] = host.ID
+	m.mu.Unlock()
 
-			host, err := agent.Host()
-			if err != nil {
-				log.Warnf("error fetching host info for agent %s: %v", endpoint, err)
-				errors = append(errors, err)
-				newFailed = append(newFailed, endpoint)
-				continue
-			}
+	m.persistAgents()
+
+	return host, err
+}
 
-			m.clients[host.ID] = NewAgentService(agent)
-			m.subscribers.Range(func(ctx context.Context, channel chan<- docker.Host) bool {
-				host.Available = true
+// RemoveAgent unregisters a previously added agent by its host ID, notifies subscribers, and
+// updates the persisted set. It also drops the endpoint from failedAgents if it hadn't managed
+// to connect yet, and tells any Provider that supplied it (see Forgettable) to stop tracking
+// the endpoint — otherwise a Provider-sourced agent would just get reconnected and
+// re-announced on the provider's next retry or health-check tick.
+func (m *RetriableClientManager) RemoveAgent(id string) error {
+	host, err := m.removeEndpointByID(id)
 
-				// We don't want to block the subscribers in event.go
-				go func() {
-					select {
-					case channel <- host:
-					case <-ctx.Done():
-					}
-				}()
+	forgetEndpoint := host.Endpoint
+	if forgetEndpoint == "" {
+		forgetEndpoint = id
+	}
+	for _, provider := range m.providers {
+		if f, ok := provider.(Forgettable); ok {
+			f.Forget(forgetEndpoint)
+		}
+	}
 
-				return true
-			})
+	m.mu.Lock()
+	for endpoint, dynID := range m.dynamicAgents {
+		if dynID == id || endpoint == id {
+			delete(m.dynamicAgents, endpoint)
+		}
+	}
+	newFailed := m.failedAgents[:0:0]
+	for _, endpoint := range m.failedAgents {
+		if endpoint != id && endpoint != host.Endpoint {
+			newFailed = append(newFailed, endpoint)
 		}
-		m.failedAgents = newFailed
 	}
+	m.failedAgents = newFailed
+	m.mu.Unlock()
 
+	m.persistAgents()
+
+	return err
+}
+
+// SubscribeEvents registers a listener for typed host membership/availability changes. The
+// returned channel is closed once ctx is done or unsubscribe is called.
+func (m *RetriableClientManager) SubscribeEvents(ctx context.Context) (<-chan HostEvent, UnsubscribeFunc) {
+	return m.events.Subscribe(ctx)
+}
+
+// Subscribe is a backwards-compatible adapter over SubscribeEvents for callers that only care
+// about the bare docker.Host and not the event type that produced it.
+func (m *RetriableClientManager) Subscribe(ctx context.Context, channel chan<- docker.Host) {
+	events, unsubscribe := m.SubscribeEvents(ctx)
+
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				select {
+				case channel <- event.Host:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+}
+
+// RetryAndList pokes the background Serve loop to retry failedAgents immediately, bypassing
+// their current backoff, nudges any Provider that supports it (see Retriggerable) to do the
+// same, and returns the client list as it stands right now. It no longer does the retry work
+// inline: callers that need up-to-date results should Subscribe for the resulting
+// host-available events rather than relying on the returned list reflecting retries that
+// haven't completed yet.
+func (m *RetriableClientManager) RetryAndList() ([]ClientService, []error) {
+	m.mu.Lock()
+	for _, endpoint := range m.failedAgents {
+		if state := m.retryStates[endpoint]; state != nil {
+			state.next = time.Time{}
+		}
+	}
 	m.mu.Unlock()
 
-	return m.List(), errors
+	select {
+	case m.trigger <- struct{}{}:
+	default:
+		// a retry is already pending; the loop will pick up the reset deadlines above
+	}
+
+	for _, provider := range m.providers {
+		if r, ok := provider.(Retriggerable); ok {
+			r.RetryNow()
+		}
+	}
+
+	return m.List(), nil
 }
 
 func (m *RetriableClientManager) List() []ClientService {
@@ -163,7 +556,11 @@ func (m *RetriableClientManager) Hosts() []docker.Host {
 		return host
 	})
 
-	for _, endpoint := range m.failedAgents {
+	m.mu.RLock()
+	failedAgents := append([]string(nil), m.failedAgents...)
+	m.mu.RUnlock()
+
+	for _, endpoint := range failedAgents {
 		hosts = append(hosts, docker.Host{
 			ID:        endpoint,
 			Name:      endpoint,
@@ -173,5 +570,11 @@ func (m *RetriableClientManager) Hosts() []docker.Host {
 		})
 	}
 
+	for _, provider := range m.providers {
+		if pl, ok := provider.(PendingLister); ok {
+			hosts = append(hosts, pl.Pending()...)
+		}
+	}
+
 	return hosts
 }
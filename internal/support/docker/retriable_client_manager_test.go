@@ -0,0 +1,41 @@
+package docker_support
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/amir20/dozzle/internal/docker"
+)
+
+// TestRemoveAgentForgetsMatchingProviderEndpoint guards against RemoveAgent's
+// forgetEndpoint-falls-back-to-id path (retriable_client_manager.go) silently failing to match a
+// StaticAgentProvider's endpoint-keyed Forget: if the fallback ever fires for a provider-sourced
+// client, the provider never learns to stop tracking the endpoint and reconnects it on its next
+// retry tick, undoing the removal.
+func TestRemoveAgentForgetsMatchingProviderEndpoint(t *testing.T) {
+	provider := NewStaticAgentProvider(nil, tls.Certificate{})
+	host := docker.Host{ID: "host-a", Endpoint: "agent-a:7007"}
+	provider.connected["agent-a:7007"] = staticAgent{client: &fakeClientService{host: host}, host: host}
+
+	m := NewRetriableClientManager(tls.Certificate{}, "", provider)
+	m.handleProviderEvent(ProviderEvent{Type: ProviderAdd, Client: provider.connected["agent-a:7007"].client, Host: host})
+
+	if _, ok := m.Find("host-a"); !ok {
+		t.Fatal("expected the provider-sourced client to be registered after the ProviderAdd event")
+	}
+
+	if err := m.RemoveAgent("host-a"); err != nil {
+		t.Fatalf("RemoveAgent() error = %v", err)
+	}
+
+	if _, ok := m.Find("host-a"); ok {
+		t.Fatal("expected RemoveAgent to drop the client from the manager")
+	}
+
+	provider.mu.Lock()
+	_, stillConnected := provider.connected["agent-a:7007"]
+	provider.mu.Unlock()
+	if stillConnected {
+		t.Fatal("expected RemoveAgent to Forget the matching provider endpoint so it isn't reconnected on the provider's next retry tick")
+	}
+}
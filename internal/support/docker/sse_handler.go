@@ -0,0 +1,58 @@
+package docker_support
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HandleHostEvents streams HostEvents to the client over SSE as they're published, one
+// `event: <type>\ndata: <json HostEvent>\n\n` frame per event, so the frontend can render
+// added/removed/available/unavailable transitions instead of just a final host list. It blocks
+// until the client disconnects or the request context is cancelled.
+func (m *RetriableClientManager) HandleHostEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := m.SubscribeEvents(r.Context())
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Warnf("error marshalling host event for SSE: %v", err)
+				continue
+			}
+
+			if _, err := w.Write([]byte("event: " + event.Type.String() + "\ndata: ")); err != nil {
+				return
+			}
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+			if _, err := w.Write([]byte("\n\n")); err != nil {
+				return
+			}
+
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package docker_support
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultSwarmAgentLabel is the service label used to find Dozzle agent services when none is
+// configured, matching `docker service create --label dozzle.agent=true ...`. This must be a
+// service-level label: reconcile discovers whole services (via ServiceList), not individual
+// tasks, since Docker never propagates a service's --label to its tasks/containers (only
+// --container-label does, and that isn't what's being matched here).
+const defaultSwarmAgentLabel = "dozzle.agent=true"
+
+// defaultSwarmAgentPort is the port Dozzle agents listen on, used to build an endpoint out of a
+// service's VIP/DNSRR name.
+const defaultSwarmAgentPort = "7007"
+
+// swarmReconcileTick bounds how long a newly scheduled or recovered service's tasks can go
+// undiscovered between service events: Swarm only emits events.ServiceEventType on a spec change
+// (scale, image update, label edit), never when a task is merely (re)scheduled onto a node after
+// a failure or finishes its initial scheduling, and task events can't be filtered by d.label
+// since task/container labels aren't the same as service labels. Polling reconcile on this tick
+// catches that case without needing to watch every task event in the cluster.
+const swarmReconcileTick = 30 * time.Second
+
+// swarmDockerClient is the subset of *client.Client that SwarmAgentDiscovery needs, narrowed so
+// tests can supply a fake Docker client instead of a live daemon connection. *client.Client
+// satisfies it without any explicit declaration on its part.
+type swarmDockerClient interface {
+	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+	ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error)
+	TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error)
+}
+
+// SwarmAgentDiscovery is a Provider that watches a Docker Swarm manager for running services
+// carrying a well-known label and announces/withdraws their VIP/DNSRR endpoint as services come
+// and go. It lets an operator deploy agents with `docker service create --mode global --label
+// dozzle.agent=true dozzle/agent` instead of hand-listing --remote-agent endpoints.
+type SwarmAgentDiscovery struct {
+	client swarmDockerClient
+	certs  tls.Certificate
+	label  string
+	dial   dialAgentFunc
+
+	mu         sync.Mutex
+	discovered map[string]docker.Host // endpoint -> host info last announced for it
+}
+
+// NewSwarmAgentDiscovery creates a discovery source that filters Swarm services by label and
+// dials discovered agent endpoints with certs. If label is empty, defaultSwarmAgentLabel is used.
+func NewSwarmAgentDiscovery(cli *client.Client, certs tls.Certificate, label string) *SwarmAgentDiscovery {
+	if label == "" {
+		label = defaultSwarmAgentLabel
+	}
+
+	return &SwarmAgentDiscovery{
+		client:     cli,
+		certs:      certs,
+		label:      label,
+		dial:       dialAgent,
+		discovered: make(map[string]docker.Host),
+	}
+}
+
+// Provide reconciles the currently running agent services, then keeps reconciling on every
+// matching service event and on swarmReconcileTick (so a task rescheduling or finishing its
+// initial scheduling without any service-level event isn't missed) until ctx is cancelled,
+// satisfying Provider.
+func (d *SwarmAgentDiscovery) Provide(ctx context.Context, out chan<- ProviderEvent) error {
+	d.reconcile(ctx, out)
+
+	messages, errs := d.client.Events(ctx, events.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ServiceEventType)),
+			filters.Arg("label", d.label),
+		),
+	})
+
+	ticker := time.NewTicker(swarmReconcileTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			if err != nil {
+				log.Warnf("error watching swarm service events: %v", err)
+			}
+		case <-ticker.C:
+			d.reconcile(ctx, out)
+		case msg := <-messages:
+			switch msg.Action {
+			case events.ActionCreate, events.ActionUpdate, events.ActionRemove:
+				d.reconcile(ctx, out)
+			}
+		}
+	}
+}
+
+// reconcile lists the services carrying the discovery label, announces the endpoint for any such
+// service that has at least one running task and isn't already discovered, and withdraws any
+// previously discovered endpoint whose service no longer matches or has gone fully unready.
+// Endpoints are built from a service's VIP/DNSRR name, which Docker load-balances across all of
+// its tasks, so only one endpoint is announced per service no matter how many tasks (or replicas)
+// are running. It connects and reports host info itself rather than calling back into the
+// manager, so the manager can mark these clients providerManaged and leave their health entirely
+// to this Provider, the same way StaticAgentProvider owns its own connections.
+func (d *SwarmAgentDiscovery) reconcile(ctx context.Context, out chan<- ProviderEvent) {
+	services, err := d.client.ServiceList(ctx, types.ServiceListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", d.label)),
+	})
+	if err != nil {
+		log.Warnf("error listing swarm services: %v", err)
+		return
+	}
+
+	seen := make(map[string]bool, len(services))
+	var toSend []ProviderEvent
+
+	for _, service := range services {
+		tasks, err := d.client.TaskList(ctx, types.TaskListOptions{
+			Filters: filters.NewArgs(
+				filters.Arg("desired-state", "running"),
+				filters.Arg("service", service.ID),
+			),
+		})
+		if err != nil {
+			log.Warnf("error listing tasks for swarm service %s: %v", service.Spec.Name, err)
+			continue
+		}
+
+		running := false
+		for _, task := range tasks {
+			if task.Status.State == swarm.TaskStateRunning {
+				running = true
+				break
+			}
+		}
+		if !running {
+			continue
+		}
+
+		endpoint := fmt.Sprintf("%s:%s", service.Spec.Name, defaultSwarmAgentPort)
+		seen[endpoint] = true
+
+		d.mu.Lock()
+		_, known := d.discovered[endpoint]
+		d.mu.Unlock()
+		if known {
+			continue
+		}
+
+		client, host, err := d.dial(endpoint, d.certs)
+		if err != nil {
+			log.Debugf("swarm agent %s not ready yet, will retry: %v", endpoint, err)
+			continue
+		}
+		host.Available = true
+
+		d.mu.Lock()
+		d.discovered[endpoint] = host
+		d.mu.Unlock()
+
+		toSend = append(toSend, ProviderEvent{Type: ProviderAdd, Client: client, Host: host})
+	}
+
+	d.mu.Lock()
+	stale := make(map[string]docker.Host)
+	for endpoint, host := range d.discovered {
+		if !seen[endpoint] {
+			stale[endpoint] = host
+			delete(d.discovered, endpoint)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, host := range stale {
+		host.Available = false
+		toSend = append(toSend, ProviderEvent{Type: ProviderRemove, Host: host})
+	}
+
+	for _, event := range toSend {
+		select {
+		case out <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Forget drops endpoint from the discovered set so an operator-initiated RemoveAgent doesn't get
+// silently undone: without this, reconcile would see the endpoint's service still running and
+// never re-announce it (since it looked "already known"), while also never cleaning it up (since
+// the service is still seen). Once forgotten, the next reconcile re-announces it as a fresh
+// ProviderAdd if its service is still running, satisfying Forgettable.
+func (d *SwarmAgentDiscovery) Forget(endpoint string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.discovered, endpoint)
+}
@@ -0,0 +1,139 @@
+package docker_support
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/amir20/dozzle/internal/docker"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// fakeSwarmDockerClient is a minimal swarmDockerClient stand-in so SwarmAgentDiscovery.reconcile
+// can be driven without a live Swarm manager.
+type fakeSwarmDockerClient struct {
+	services []swarm.Service
+	tasks    map[string][]swarm.Task // service ID -> its running tasks
+}
+
+func (f *fakeSwarmDockerClient) Events(ctx context.Context, _ events.ListOptions) (<-chan events.Message, <-chan error) {
+	messages := make(chan events.Message)
+	errs := make(chan error)
+	return messages, errs
+}
+
+func (f *fakeSwarmDockerClient) ServiceList(_ context.Context, _ types.ServiceListOptions) ([]swarm.Service, error) {
+	return f.services, nil
+}
+
+func (f *fakeSwarmDockerClient) TaskList(_ context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	serviceID := options.Filters.Get("service")[0]
+	return f.tasks[serviceID], nil
+}
+
+func runningService(id, name string) (swarm.Service, swarm.Task) {
+	service := swarm.Service{ID: id, Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: name}}}
+	task := swarm.Task{ServiceID: id, Status: swarm.TaskStatus{State: swarm.TaskStateRunning}}
+	return service, task
+}
+
+func TestSwarmAgentDiscoveryReconcileAnnouncesRunningService(t *testing.T) {
+	service, task := runningService("svc-1", "dozzle-agent")
+
+	d := NewSwarmAgentDiscovery(nil, tls.Certificate{}, "")
+	d.client = &fakeSwarmDockerClient{
+		services: []swarm.Service{service},
+		tasks:    map[string][]swarm.Task{"svc-1": {task}},
+	}
+	d.dial = func(endpoint string, _ tls.Certificate) (ClientService, docker.Host, error) {
+		return &fakeClientService{}, docker.Host{ID: "host-1", Endpoint: endpoint}, nil
+	}
+
+	out := make(chan ProviderEvent, 1)
+	d.reconcile(context.Background(), out)
+
+	select {
+	case event := <-out:
+		if event.Type != ProviderAdd || event.Host.Endpoint != "dozzle-agent:7007" {
+			t.Fatalf("got event %+v, want ProviderAdd for dozzle-agent:7007", event)
+		}
+	default:
+		t.Fatal("expected reconcile to announce the running service, got no event")
+	}
+}
+
+func TestSwarmAgentDiscoveryReconcileWithdrawsStoppedService(t *testing.T) {
+	service, task := runningService("svc-1", "dozzle-agent")
+
+	client := &fakeSwarmDockerClient{
+		services: []swarm.Service{service},
+		tasks:    map[string][]swarm.Task{"svc-1": {task}},
+	}
+
+	d := NewSwarmAgentDiscovery(nil, tls.Certificate{}, "")
+	d.client = client
+	d.dial = func(endpoint string, _ tls.Certificate) (ClientService, docker.Host, error) {
+		return &fakeClientService{}, docker.Host{ID: "host-1", Endpoint: endpoint}, nil
+	}
+
+	firstOut := make(chan ProviderEvent, 1)
+	d.reconcile(context.Background(), firstOut)
+	<-firstOut // drain the initial ProviderAdd
+
+	// The service disappears entirely (e.g. removed).
+	client.services = nil
+	client.tasks = nil
+
+	out := make(chan ProviderEvent, 1)
+	d.reconcile(context.Background(), out)
+
+	select {
+	case event := <-out:
+		if event.Type != ProviderRemove || event.Host.Endpoint != "dozzle-agent:7007" {
+			t.Fatalf("got event %+v, want ProviderRemove for dozzle-agent:7007", event)
+		}
+	default:
+		t.Fatal("expected reconcile to withdraw the now-gone service, got no event")
+	}
+}
+
+func TestSwarmAgentDiscoveryForgetAllowsReannouncement(t *testing.T) {
+	service, task := runningService("svc-1", "dozzle-agent")
+
+	d := NewSwarmAgentDiscovery(nil, tls.Certificate{}, "")
+	d.client = &fakeSwarmDockerClient{
+		services: []swarm.Service{service},
+		tasks:    map[string][]swarm.Task{"svc-1": {task}},
+	}
+	d.dial = func(endpoint string, _ tls.Certificate) (ClientService, docker.Host, error) {
+		return &fakeClientService{}, docker.Host{ID: "host-1", Endpoint: endpoint}, nil
+	}
+
+	firstOut := make(chan ProviderEvent, 1)
+	d.reconcile(context.Background(), firstOut)
+	<-firstOut
+
+	// Without Forget, the service is still running and "known", so reconcile wouldn't re-announce it.
+	noOpOut := make(chan ProviderEvent, 1)
+	d.reconcile(context.Background(), noOpOut)
+	select {
+	case event := <-noOpOut:
+		t.Fatalf("expected no re-announcement for an already-discovered endpoint, got %+v", event)
+	default:
+	}
+
+	d.Forget("dozzle-agent:7007")
+
+	out := make(chan ProviderEvent, 1)
+	d.reconcile(context.Background(), out)
+	select {
+	case event := <-out:
+		if event.Type != ProviderAdd || event.Host.Endpoint != "dozzle-agent:7007" {
+			t.Fatalf("got event %+v, want a fresh ProviderAdd after Forget", event)
+		}
+	default:
+		t.Fatal("expected Forget to let reconcile re-announce the endpoint, got no event")
+	}
+}